@@ -4,18 +4,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/alexellis/k3sup/pkg"
 	"github.com/spf13/cobra"
 )
 
+const (
+	roleServer = "server"
+	roleAgent  = "agent"
+	roleAuto   = "auto"
+)
+
+const (
+	haModeEmbeddedEtcd = "embedded-etcd"
+	haModeExternalDB   = "external-db"
+	haModeSingle       = "single"
+)
+
 func MakePlan() *cobra.Command {
 	var initFlag bool
 
 	var command = &cobra.Command{
 		Use:   "plan",
 		Short: "Plan an installation of K3s.",
-		Long: `Generate a bash script or plan of installation commands for K3s for a 
+		Long: `Generate a bash script or plan of installation commands for K3s for a
 Highly Available (HA) Kubernetes cluster.
 
 Examples JSON input file:
@@ -24,6 +39,27 @@ Examples JSON input file:
 {"hostname": "node-2", "ip": "192.168.128.103"},
 {"hostname": "node-3", "ip": "192.168.128.104"}]
 
+Hosts may also be given a "role" of "server", "agent" or "auto" (the
+default), along with "internal-ip"/"external-ip", "ssh-user", "ssh-key",
+"ssh-port", "k3s-extra-args", "labels", "taints" and "tls-san" to override
+the cluster-wide defaults on a per-host basis. Run "k3sup plan --init" to
+see a fully populated example.
+
+The plan file can also be a YAML document (auto-detected from a .yaml/.yml
+extension, or forced with --format), and can be a full cluster spec: a
+"hosts" list alongside cluster-wide defaults such as "user", "ssh-key",
+"tls-san" and the datastore/HA settings below, which fill in any field a
+host itself leaves empty. CLI flags you pass explicitly always win over
+the spec's defaults. Run "k3sup plan --init --init-format yaml" to see a
+fully populated example. Use "k3sup plan validate <file>" to lint a plan
+file without generating a script.
+
+By default the plan is printed as a bash script. Pass --execute to have
+k3sup drive the installation itself: the primary server is installed
+first, additional servers and agents are then joined concurrently (up to
+--parallelism at a time) and each phase waits for its nodes to report
+Ready before the next one starts.
+
 ` + pkg.SupportMessageShort + `
 `,
 		Example: `  # Generate an installation script where the first
@@ -31,8 +67,17 @@ Examples JSON input file:
   # The remaining hosts are added as agents.
   k3sup plan hosts.json --servers 3 --user ubuntu
 
-  # Override the TLS SAN, for HA with 5 servers specified
-  k3sup plan hosts.json --servers 5 --tls-san $SAN_IP
+  # Override the TLS SANs, for HA with 5 servers specified
+  k3sup plan hosts.json --servers 5 --tls-san $SAN_IP --tls-san k3s.example.com
+
+  # Drive the install directly instead of printing a script
+  k3sup plan hosts.json --servers 3 --execute --parallelism 2
+
+  # Plan from a YAML cluster spec
+  k3sup plan cluster.yaml
+
+  # Check a plan file for mistakes without generating a script
+  k3sup plan validate cluster.yaml --probe
 
   # Create an example hosts.json file
   k3sup plan --init
@@ -40,11 +85,16 @@ Examples JSON input file:
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if initFlag {
+				initFormat, _ := cmd.Flags().GetString("init-format")
+				if initFormat == "yaml" {
+					fmt.Println(exampleClusterSpecYAML())
+					return nil
+				}
 				return outputExampleHostsJSON()
 			}
 
 			if len(args) == 0 {
-				return fmt.Errorf("give a path to a JSON file containing a list of devices")
+				return fmt.Errorf("give a path to a JSON or YAML file containing a list of devices")
 			}
 
 			nodeLimit, _ := cmd.Flags().GetInt("limit")
@@ -56,100 +106,150 @@ Examples JSON input file:
 
 			background, _ := cmd.Flags().GetBool("background")
 
-			var hosts []Host
-			if err = json.Unmarshal(data, &hosts); err != nil {
+			formatFlag, _ := cmd.Flags().GetString("format")
+			spec, err := parseClusterSpec(data, detectFormat(name, formatFlag))
+			if err != nil {
 				return err
 			}
 
-			serverK3sExtraArgs, _ := cmd.Flags().GetString("server-k3s-extra-args")
-			agentK3sExtraArgs, _ := cmd.Flags().GetString("agent-k3s-extra-args")
+			hosts := spec.Hosts
+			if nodeLimit > 0 && nodeLimit < len(hosts) {
+				hosts = hosts[:nodeLimit]
+			}
+
+			cfg := resolveConfig(cmd, spec)
 
-			servers, _ := cmd.Flags().GetInt("servers")
-			kubeconfig, _ := cmd.Flags().GetString("local-path")
-			contextName, _ := cmd.Flags().GetString("context")
-			user, _ := cmd.Flags().GetString("user")
-			tlsSan, _ := cmd.Flags().GetString("tls-san")
+			servers := cfg.servers
+			kubeconfig := cfg.kubeconfig
+			contextName := cfg.contextName
+			user := cfg.user
+			sshKey := cfg.sshKey
+			sshPort := cfg.sshPort
+			tlsSans := cfg.tlsSans
 
-			tlsSanStr := ""
-			if len(tlsSan) > 0 {
-				tlsSanStr = fmt.Sprintf(` \
---tls-san %s`, tlsSan)
-			}
-			// sshKey, _ := cmd.Flags().GetString("ssh-key")
+			haMode := cfg.haMode
+			datastoreEndpoint := cfg.datastoreEndpoint
+			datastoreToken := cfg.datastoreToken
+			datastoreCAFile := cfg.datastoreCAFile
+			datastoreCertFile := cfg.datastoreCertFile
+			datastoreKeyFile := cfg.datastoreKeyFile
+
+			execute, _ := cmd.Flags().GetBool("execute")
+			parallelism, _ := cmd.Flags().GetInt("parallelism")
+			readyTimeout, _ := cmd.Flags().GetDuration("ready-timeout")
 
 			bgStr := ""
 			if background {
 				bgStr = " &"
 			}
 
-			serversAdded := 0
-			var primaryServer Host
-			script := "#!/bin/sh\n\n"
+			if err := validateHAModeFlag(haMode, datastoreEndpoint, datastoreToken); err != nil {
+				return err
+			}
+
+			serverHosts, agentHosts, err := assignRoles(hosts, servers, haMode)
+			if err != nil {
+				return err
+			}
+
+			if haMode == haModeSingle && len(serverHosts) != 1 {
+				return fmt.Errorf("--ha-mode=%s requires exactly 1 server, got %d", haModeSingle, len(serverHosts))
+			}
 
-			serverExtraArgsSt := ""
-			if len(serverK3sExtraArgs) > 0 {
-				serverExtraArgsSt = fmt.Sprintf(` \
---k3s-extra-args "%s"`, serverK3sExtraArgs)
+			datastoreArgsSt := ""
+			if haMode == haModeExternalDB {
+				datastoreArgsSt = fmt.Sprintf(` \
+--datastore "%s" \
+--token "%s"`, datastoreEndpoint, datastoreToken)
 			}
-			agentExtraArgsSt := ""
-			if len(agentK3sExtraArgs) > 0 {
-				agentExtraArgsSt = fmt.Sprintf(` \
---k3s-extra-args "%s"`, agentK3sExtraArgs)
+
+			script := "#!/bin/sh\n\n"
+			script += haModeBanner(haMode, len(serverHosts))
+
+			channelVersionArgsSt := k3sChannelVersionArgs(cfg.k3sChannel, cfg.k3sVersion)
+
+			var primaryServer Host
+
+			clusterOrDatastoreSt := ""
+			switch haMode {
+			case haModeExternalDB:
+				clusterOrDatastoreSt = datastoreArgsSt
+			case haModeSingle:
+				// No --cluster and no --datastore-*: a single server uses k3s's
+				// default sqlite datastore, not embedded etcd.
+			default:
+				clusterOrDatastoreSt = " \\\n--cluster"
 			}
 
-			for i, host := range hosts {
-				if serversAdded == 0 {
+			for i, host := range serverHosts {
+				hostUser := user
+				if host.SSHUser != "" {
+					hostUser = host.SSHUser
+				}
+
+				dsCAFile, dsCertFile, dsKeyFile := "", "", ""
+				if haMode == haModeExternalDB {
+					dsCAFile, dsCertFile, dsKeyFile = datastoreCAFile, datastoreCertFile, datastoreKeyFile
+				}
+				nativeArgs := k3sNativeArgs(host, dsCAFile, dsCertFile, dsKeyFile)
+				hostExtraArgsSt := channelVersionArgsSt + k3sExtraArgsSt(k3sExtraArgsValue(nativeArgs, hostExtraArgs(host, cfg.serverExtra)))
 
+				if i == 0 {
 					script += `echo "Setting up primary server 1"
 `
-
-					script += fmt.Sprintf(`k3sup install --host %s \
---user %s \
---cluster \
---local-path %s \
---context %s%s%s
-`,
-						host.IP,
-						user,
-						kubeconfig,
-						contextName,
-						tlsSanStr,
-						serverExtraArgsSt)
+					script += installCmdString(host, hostUser, kubeconfig, contextName, clusterOrDatastoreSt, tlsSans, sshKey, sshPort, hostExtraArgsSt)
 
 					script += fmt.Sprintf(`
 echo "Fetching the server's node-token into memory"
 
-export NODE_TOKEN=$(k3sup node-token --host %s --user %s)
-`, host.IP, user)
+export NODE_TOKEN=$(%s)
+`, nodeTokenFetchCmdString(host, hostUser, sshKey, sshPort))
 
-					serversAdded = 1
 					primaryServer = host
-				} else if serversAdded < servers {
-					script += fmt.Sprintf("\necho \"Setting up additional server: %d\"\n", serversAdded+1)
-
-					script += fmt.Sprintf(`k3sup join \
---host %s \
---server-host %s \
---server \
---node-token "$NODE_TOKEN" \
---user %s%s%s%s
-`, host.IP, primaryServer.IP, user, tlsSanStr, serverExtraArgsSt, bgStr)
-
-					serversAdded++
+				} else if haMode == haModeExternalDB {
+					script += fmt.Sprintf("\necho \"Setting up additional server: %d (external datastore)\"\n", i+1)
+					script += installCmdString(host, hostUser, kubeconfig, contextName, clusterOrDatastoreSt, tlsSans, sshKey, sshPort, hostExtraArgsSt+bgStr)
 				} else {
-					script += fmt.Sprintf("\necho \"Setting up worker: %d\"\n", (i+1)-serversAdded)
-
-					script += fmt.Sprintf(`k3sup join \
---host %s \
---server-host %s \
---node-token "$NODE_TOKEN" \
---user %s%s%s
-`, host.IP, primaryServer.IP, user, agentExtraArgsSt, bgStr)
+					script += fmt.Sprintf("\necho \"Setting up additional server: %d\"\n", i+1)
+					script += serverJoinCmdString(host, primaryServer.IP, hostUser, `"$NODE_TOKEN"`, tlsSans, sshKey, sshPort, hostExtraArgsSt, bgStr)
 				}
+			}
 
-				if nodeLimit > 0 && i+1 >= nodeLimit {
-					break
+			for i, host := range agentHosts {
+				hostUser := user
+				if host.SSHUser != "" {
+					hostUser = host.SSHUser
 				}
+
+				hostExtraArgsSt := channelVersionArgsSt + k3sExtraArgsSt(k3sExtraArgsValue(k3sNativeArgs(host, "", "", ""), hostExtraArgs(host, cfg.agentExtra)))
+
+				script += fmt.Sprintf("\necho \"Setting up worker: %d\"\n", i+1)
+				script += agentJoinCmdString(host, primaryServer.IP, hostUser, `"$NODE_TOKEN"`, sshKey, sshPort, hostExtraArgsSt, bgStr)
+			}
+
+			if execute {
+				return executePlan(executePlanOpts{
+					serverHosts:       serverHosts,
+					agentHosts:        agentHosts,
+					user:              user,
+					sshKey:            sshKey,
+					sshPort:           sshPort,
+					kubeconfig:        kubeconfig,
+					contextName:       contextName,
+					tlsSans:           tlsSans,
+					serverExtra:       cfg.serverExtra,
+					agentExtra:        cfg.agentExtra,
+					k3sChannel:        cfg.k3sChannel,
+					k3sVersion:        cfg.k3sVersion,
+					haMode:            haMode,
+					datastoreEndpoint: datastoreEndpoint,
+					datastoreToken:    datastoreToken,
+					datastoreCAFile:   datastoreCAFile,
+					datastoreCertFile: datastoreCertFile,
+					datastoreKeyFile:  datastoreKeyFile,
+					parallelism:       parallelism,
+					readyTimeout:      readyTimeout,
+				})
 			}
 
 			fmt.Printf("%s\n", script)
@@ -164,24 +264,318 @@ export NODE_TOKEN=$(k3sup node-token --host %s --user %s)
 	command.Flags().String("user", "root", "Username for SSH login")
 
 	command.Flags().String("ssh-key", "", "Path to the private key for SSH login")
-	command.Flags().String("tls-san", "", "SAN for TLS certificates, can be a comma-separated list")
+	command.Flags().Int("ssh-port", 22, "Port for SSH login")
+	command.Flags().StringSlice("tls-san", []string{}, "SAN for TLS certificates, can be given multiple times or as a comma-separated list")
 	command.Flags().String("server-k3s-extra-args", "", "Extra arguments to be passed into the k3s server")
 	command.Flags().String("agent-k3s-extra-args", "", "Extra arguments to be passed into the k3s agent")
+	command.Flags().String("k3s-channel", "", "K3s release channel to install, e.g. stable or latest")
+	command.Flags().String("k3s-version", "", "K3s version to install, e.g. v1.30.2+k3s1")
+
+	command.Flags().String("ha-mode", haModeEmbeddedEtcd, "HA topology to plan for: embedded-etcd, external-db or single")
+	command.Flags().String("datastore-endpoint", "", "Endpoint for an external datastore (e.g. PostgreSQL, MySQL or etcd), used when --ha-mode=external-db")
+	command.Flags().String("datastore-token", "", "Shared secret token for an external datastore, required when --ha-mode=external-db")
+	command.Flags().String("datastore-cafile", "", "TLS Certificate Authority file used to secure the datastore connection")
+	command.Flags().String("datastore-certfile", "", "TLS certificate file used to secure the datastore connection")
+	command.Flags().String("datastore-keyfile", "", "TLS key file used to secure the datastore connection")
+
+	command.Flags().Bool("execute", false, "Drive the installation directly over SSH instead of only printing a script")
+	command.Flags().Int("parallelism", 4, "Maximum number of nodes to install/join concurrently when --execute is set")
+	command.Flags().Duration("ready-timeout", 5*time.Minute, "How long to wait for each phase's nodes to report Ready when --execute is set")
 
 	// Background
 	command.Flags().Bool("background", false, "Run the installation in the background for all agents/nodes after the first server is up")
 
 	command.Flags().Int("limit", 0, "Maximum number of nodes to use from the devices file, 0 to use all devices")
 	command.Flags().BoolVar(&initFlag, "init", false, "Output an example hosts.json file")
+	command.Flags().String("init-format", "json", "Format for --init: json or yaml")
+	command.Flags().String("format", "", "Force the plan file format to json or yaml instead of detecting it from the file extension")
+
+	command.AddCommand(MakePlanValidate())
 
 	return command
 }
 
+// assignRoles splits hosts into servers and agents. Hosts with an explicit
+// "server" or "agent" role are honored regardless of serverCount. Hosts with
+// role "auto" (or no role set, for backwards compatibility with the flat
+// {hostname, ip} schema) are promoted to servers until serverCount auto
+// promotions have been made, then become agents.
+//
+// An even number of servers is refused unless haMode is external-db: an
+// external datastore holds consensus itself, so k3s servers don't need an
+// odd count to form an etcd quorum.
+func assignRoles(hosts []Host, serverCount int, haMode string) (servers []Host, agents []Host, err error) {
+	autoCount := 0
+	for _, host := range hosts {
+		if host.Role == "" || host.Role == roleAuto {
+			autoCount++
+		}
+	}
+
+	if serverCount > autoCount {
+		return nil, nil, fmt.Errorf("--servers %d exceeds the number of auto/unassigned hosts available (%d)", serverCount, autoCount)
+	}
+
+	autoServersAssigned := 0
+	for _, host := range hosts {
+		switch host.Role {
+		case roleServer:
+			servers = append(servers, host)
+		case roleAgent:
+			agents = append(agents, host)
+		case roleAuto, "":
+			if autoServersAssigned < serverCount {
+				servers = append(servers, host)
+				autoServersAssigned++
+			} else {
+				agents = append(agents, host)
+			}
+		default:
+			return nil, nil, fmt.Errorf(`invalid role %q for host %q, must be "server", "agent" or "auto"`, host.Role, host.Hostname)
+		}
+	}
+
+	if len(servers) == 0 {
+		return nil, nil, fmt.Errorf("at least one server is required, got 0 from %d hosts", len(hosts))
+	}
+
+	if len(servers)%2 == 0 && haMode != haModeExternalDB {
+		return nil, nil, fmt.Errorf("%d servers were planned, but an even number of servers cannot form an HA quorum", len(servers))
+	}
+
+	return servers, agents, nil
+}
+
+// validateHAModeFlag checks that --ha-mode, --datastore-endpoint and
+// --datastore-token are consistent with one another. k3sup requires a
+// shared --token whenever --datastore is used, since an external datastore
+// holds no cluster secret of its own for servers to agree on.
+func validateHAModeFlag(haMode string, datastoreEndpoint, datastoreToken string) error {
+	switch haMode {
+	case haModeEmbeddedEtcd, haModeSingle:
+		if datastoreEndpoint != "" {
+			return fmt.Errorf("--datastore-endpoint was given but --ha-mode=%s does not use an external datastore", haMode)
+		}
+	case haModeExternalDB:
+		if datastoreEndpoint == "" {
+			return fmt.Errorf("--ha-mode=%s requires --datastore-endpoint", haModeExternalDB)
+		}
+		if datastoreToken == "" {
+			return fmt.Errorf("--ha-mode=%s requires --datastore-token", haModeExternalDB)
+		}
+	default:
+		return fmt.Errorf("invalid --ha-mode %q, must be %q, %q or %q", haMode, haModeEmbeddedEtcd, haModeExternalDB, haModeSingle)
+	}
+
+	return nil
+}
+
+// haModeBanner returns an echo line announcing the HA topology being
+// deployed, so operators can see at a glance which shape the script takes.
+func haModeBanner(haMode string, serverCount int) string {
+	switch haMode {
+	case haModeExternalDB:
+		return fmt.Sprintf("echo \"Deploying %d server(s) against an external datastore\"\n\n", serverCount)
+	case haModeSingle:
+		return "echo \"Deploying a single server, no HA\"\n\n"
+	default:
+		return fmt.Sprintf("echo \"Deploying %d server(s) with embedded etcd HA\"\n\n", serverCount)
+	}
+}
+
+// installCmdString builds a "k3sup install" invocation for a server node.
+// clusterOrDatastoreSt carries either " \\\n--cluster" (embedded etcd), the
+// --datastore and --token flags (external datastore) or nothing (single),
+// computed once by the caller.
+func installCmdString(host Host, hostUser, kubeconfig, contextName, clusterOrDatastoreSt string, tlsSans []string, sshKey string, sshPort int, extraArgsSt string) string {
+	return fmt.Sprintf(`k3sup install --host %s \
+--user %s%s \
+--local-path %s \
+--context %s%s%s%s%s
+`,
+		host.IP,
+		hostUser,
+		clusterOrDatastoreSt,
+		kubeconfig,
+		contextName,
+		tlsSanArgs(tlsSans, host.TLSSan),
+		ipOverrideArgs(host),
+		sshOverrideArgs(host, sshKey, sshPort),
+		extraArgsSt)
+}
+
+// serverJoinCmdString builds a "k3sup join --server" invocation used to add
+// an additional embedded-etcd server in script output. tokenRef is the
+// literal shell variable reference ("$NODE_TOKEN"); --execute builds its
+// argv with serverJoinCmdArgs instead.
+func serverJoinCmdString(host Host, primaryIP, hostUser, tokenRef string, tlsSans []string, sshKey string, sshPort int, extraArgsSt, bgStr string) string {
+	return fmt.Sprintf(`k3sup join \
+--host %s \
+--server-host %s \
+--server \
+--node-token %s \
+--user %s%s%s%s%s
+`, host.IP, primaryIP, tokenRef, hostUser, tlsSanArgs(tlsSans, host.TLSSan), ipOverrideArgs(host), sshOverrideArgs(host, sshKey, sshPort), extraArgsSt+bgStr)
+}
+
+// agentJoinCmdString builds a "k3sup join" invocation for an agent node.
+func agentJoinCmdString(host Host, primaryIP, hostUser, tokenRef string, sshKey string, sshPort int, extraArgsSt, bgStr string) string {
+	return fmt.Sprintf(`k3sup join \
+--host %s \
+--server-host %s \
+--node-token %s \
+--user %s%s%s%s
+`, host.IP, primaryIP, tokenRef, hostUser, ipOverrideArgs(host), sshOverrideArgs(host, sshKey, sshPort), extraArgsSt+bgStr)
+}
+
+// nodeTokenFetchCmdString builds the "k3sup node-token" invocation used
+// inside "export NODE_TOKEN=$(...)" in script output; --execute builds its
+// argv with nodeTokenFetchCmdArgs instead. sshKey/sshPort mirror the same
+// per-host SSH overrides used to install the primary server, so fetching
+// its node-token still works when that host needs a non-default key or
+// port.
+func nodeTokenFetchCmdString(host Host, hostUser string, sshKey string, sshPort int) string {
+	return fmt.Sprintf("k3sup node-token --host %s --user %s%s", host.IP, hostUser, sshOverrideArgs(host, sshKey, sshPort))
+}
+
+// tlsSanArgs merges the global --tls-san values with any SANs set on the
+// host itself, de-duplicates the combined list, and emits a single
+// --tls-san flag with a comma-separated value: unlike k3s's own native
+// --tls-san, k3sup's --tls-san is a plain string flag, so repeating it
+// would leave only the last SAN in effect.
+func tlsSanArgs(globalSans []string, hostSans []string) string {
+	sans := dedupeStrings(append(append([]string{}, globalSans...), hostSans...))
+	if len(sans) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(` \
+--tls-san %s`, strings.Join(sans, ","))
+}
+
+// k3sNativeArgs assembles the k3s-native flags that k3sup itself does not
+// accept as top-level arguments: k3sup only forwards them to the k3s
+// installer through its own single --k3s-extra-args value, so they have to
+// travel inside that value's text (see k3sExtraArgsValue) rather than as
+// separate flags on "k3sup install"/"k3sup join".
+//
+// --node-name is always included so the host's "hostname" label always
+// matches the name k3s registers the node under, regardless of the node's
+// actual OS hostname (this is what --execute's readiness gate polls for).
+// --node-external-ip, --node-label and --node-taint are included whenever
+// the host sets them, with labels sorted by key so the generated value is
+// deterministic. datastoreCAFile/datastoreCertFile/datastoreKeyFile secure
+// the connection to an external datastore and are only meaningful for a
+// server install under --ha-mode=external-db; callers pass empty strings
+// otherwise.
+func k3sNativeArgs(host Host, datastoreCAFile, datastoreCertFile, datastoreKeyFile string) string {
+	args := fmt.Sprintf("--node-name %s", host.Hostname)
+
+	if host.ExternalIP != "" {
+		args += fmt.Sprintf(" --node-external-ip %s", host.ExternalIP)
+	}
+
+	keys := make([]string, 0, len(host.Labels))
+	for k := range host.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args += fmt.Sprintf(` --node-label "%s=%s"`, k, host.Labels[k])
+	}
+
+	for _, taint := range host.Taints {
+		args += fmt.Sprintf(` --node-taint "%s"`, taint)
+	}
+
+	if datastoreCAFile != "" {
+		args += fmt.Sprintf(" --datastore-cafile %s", datastoreCAFile)
+	}
+	if datastoreCertFile != "" {
+		args += fmt.Sprintf(" --datastore-certfile %s", datastoreCertFile)
+	}
+	if datastoreKeyFile != "" {
+		args += fmt.Sprintf(" --datastore-keyfile %s", datastoreKeyFile)
+	}
+
+	return args
+}
+
+// k3sExtraArgsValue merges the k3s-native flags from k3sNativeArgs with the
+// caller's own --k3s-extra-args text into the single value k3sup forwards
+// verbatim to the k3s installer.
+func k3sExtraArgsValue(nativeArgs, userExtra string) string {
+	if userExtra == "" {
+		return nativeArgs
+	}
+	return nativeArgs + " " + userExtra
+}
+
+// k3sExtraArgsSt wraps value as a single --k3s-extra-args "..." flag for
+// script output.
+func k3sExtraArgsSt(value string) string {
+	return fmt.Sprintf(` \
+--k3s-extra-args "%s"`, value)
+}
+
+// ipOverrideArgs emits --ip when the host specifies its own internal IP,
+// for use with multi-homed nodes. (--node-external-ip is a k3s-native flag,
+// not a k3sup one, so it travels inside --k3s-extra-args instead; see
+// k3sNativeArgs.)
+func ipOverrideArgs(host Host) string {
+	if host.InternalIP == "" {
+		return ""
+	}
+	return fmt.Sprintf(` \
+--ip %s`, host.InternalIP)
+}
+
+// sshOverrideArgs emits --ssh-key/--ssh-port when the host overrides the
+// cluster-wide default, falling back to the global flag values.
+func sshOverrideArgs(host Host, defaultSSHKey string, defaultSSHPort int) string {
+	args := ""
+
+	sshKey := defaultSSHKey
+	if host.SSHKey != "" {
+		sshKey = host.SSHKey
+	}
+	if sshKey != "" {
+		args += fmt.Sprintf(` \
+--ssh-key %s`, sshKey)
+	}
+
+	sshPort := defaultSSHPort
+	if host.SSHPort != 0 {
+		sshPort = host.SSHPort
+	}
+	if sshPort != 0 && sshPort != 22 {
+		args += fmt.Sprintf(` \
+--ssh-port %d`, sshPort)
+	}
+
+	return args
+}
+
 func outputExampleHostsJSON() error {
 	exampleHosts := []Host{
-		{Hostname: "node-1", IP: "192.168.128.102"},
-		{Hostname: "node-2", IP: "192.168.128.103"},
-		{Hostname: "node-3", IP: "192.168.128.104"},
+		{
+			Hostname: "node-1",
+			IP:       "192.168.128.102",
+			Role:     roleServer,
+		},
+		{
+			Hostname:   "node-2",
+			IP:         "192.168.128.103",
+			Role:       roleAgent,
+			ExternalIP: "203.0.113.10",
+			TLSSan:     []string{"203.0.113.10"},
+			SSHUser:    "ubuntu",
+			SSHKey:     "~/.ssh/id_rsa",
+			SSHPort:    22,
+			Labels:     map[string]string{"disktype": "ssd"},
+			Taints:     []string{"dedicated=gpu:NoSchedule"},
+		},
+		{Hostname: "node-3", IP: "192.168.128.104", Role: roleAuto},
 	}
 
 	data, err := json.MarshalIndent(exampleHosts, "", "  ")
@@ -193,7 +587,30 @@ func outputExampleHostsJSON() error {
 	return nil
 }
 
+// Host describes a single node to be installed by "k3sup plan". The flat
+// {hostname, ip} schema remains valid: an empty Role is treated as "auto".
 type Host struct {
-	Hostname string `json:"hostname"`
-	IP       string `json:"ip"`
+	Hostname string `json:"hostname" yaml:"hostname"`
+	IP       string `json:"ip" yaml:"ip"`
+
+	// Role is "server", "agent" or "auto" (the default). Hosts with an
+	// explicit role are honored ahead of the --servers count, which only
+	// governs how many "auto" hosts are promoted to servers.
+	Role string `json:"role,omitempty" yaml:"role,omitempty"`
+
+	InternalIP string `json:"internal-ip,omitempty" yaml:"internal-ip,omitempty"`
+	ExternalIP string `json:"external-ip,omitempty" yaml:"external-ip,omitempty"`
+
+	// TLSSan lists node-specific TLS SANs (e.g. this server's own external
+	// IP) which are appended to the global --tls-san list for this host only.
+	TLSSan []string `json:"tls-san,omitempty" yaml:"tls-san,omitempty"`
+
+	SSHUser string `json:"ssh-user,omitempty" yaml:"ssh-user,omitempty"`
+	SSHKey  string `json:"ssh-key,omitempty" yaml:"ssh-key,omitempty"`
+	SSHPort int    `json:"ssh-port,omitempty" yaml:"ssh-port,omitempty"`
+
+	K3sExtraArgs string `json:"k3s-extra-args,omitempty" yaml:"k3s-extra-args,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Taints []string          `json:"taints,omitempty" yaml:"taints,omitempty"`
 }
@@ -0,0 +1,368 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// NodeStatus reports the outcome of installing or joining a single node, so
+// that callers other than the plain stdout logger below (a future TUI, for
+// example) can render --execute progress.
+type NodeStatus struct {
+	Hostname string
+	Role     string
+	State    string
+	Err      error
+}
+
+func (s NodeStatus) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("[%s] %s: failed: %s", s.Role, s.Hostname, s.Err)
+	}
+	return fmt.Sprintf("[%s] %s: %s", s.Role, s.Hostname, s.State)
+}
+
+// executePlanOpts carries everything executePlan needs to reconstruct the
+// same k3sup invocations that would otherwise be printed as a script.
+type executePlanOpts struct {
+	serverHosts []Host
+	agentHosts  []Host
+
+	user    string
+	sshKey  string
+	sshPort int
+
+	kubeconfig  string
+	contextName string
+
+	tlsSans     []string
+	serverExtra string
+	agentExtra  string
+	k3sChannel  string
+	k3sVersion  string
+
+	haMode            string
+	datastoreEndpoint string
+	datastoreToken    string
+	datastoreCAFile   string
+	datastoreCertFile string
+	datastoreKeyFile  string
+
+	parallelism  int
+	readyTimeout time.Duration
+}
+
+// executePlan drives the installation described by opts directly over SSH:
+// the primary server is installed synchronously, then additional servers
+// and agents are joined through a parallelism-capped errgroup, with a
+// readiness gate between phases so agents never join before the server
+// quorum is up.
+func executePlan(opts executePlanOpts) error {
+	if len(opts.serverHosts) == 0 {
+		return fmt.Errorf("no server hosts to install")
+	}
+
+	primary := opts.serverHosts[0]
+	primaryUser := hostUser(primary, opts.user)
+
+	reportStatus(NodeStatus{Hostname: primary.Hostname, Role: roleServer, State: "installing primary server"})
+
+	dsCAFile, dsCertFile, dsKeyFile := "", "", ""
+	if opts.haMode == haModeExternalDB {
+		dsCAFile, dsCertFile, dsKeyFile = opts.datastoreCAFile, opts.datastoreCertFile, opts.datastoreKeyFile
+	}
+	extraValue := k3sExtraArgsValue(k3sNativeArgs(primary, dsCAFile, dsCertFile, dsKeyFile), hostExtraArgs(primary, opts.serverExtra))
+	extra := extraArgsList(opts.k3sChannel, opts.k3sVersion, extraValue)
+	if _, err := runK3supCommand(installCmdArgs(primary, primaryUser, opts, extra)); err != nil {
+		reportStatus(NodeStatus{Hostname: primary.Hostname, Role: roleServer, State: "failed", Err: err})
+		return fmt.Errorf("installing primary server %s: %w", primary.Hostname, err)
+	}
+	reportStatus(NodeStatus{Hostname: primary.Hostname, Role: roleServer, State: "installed"})
+
+	nodeToken, err := runK3supCommand(nodeTokenFetchCmdArgs(primary, primaryUser, opts.sshKey, opts.sshPort))
+	if err != nil {
+		return fmt.Errorf("fetching node-token from %s: %w", primary.Hostname, err)
+	}
+	token := strings.TrimSpace(nodeToken)
+
+	if err := joinHosts(opts.serverHosts[1:], roleServer, opts, primary, token); err != nil {
+		return err
+	}
+
+	if err := waitForNodesReady(opts.kubeconfig, hostnames(opts.serverHosts), opts.readyTimeout); err != nil {
+		return fmt.Errorf("waiting for servers to become Ready: %w", err)
+	}
+
+	if err := joinHosts(opts.agentHosts, roleAgent, opts, primary, token); err != nil {
+		return err
+	}
+
+	if err := waitForNodesReady(opts.kubeconfig, hostnames(opts.agentHosts), opts.readyTimeout); err != nil {
+		return fmt.Errorf("waiting for agents to become Ready: %w", err)
+	}
+
+	return nil
+}
+
+// joinHosts fans additional servers or agents out to a parallelism-capped
+// errgroup, reporting a NodeStatus for each as it completes.
+func joinHosts(hosts []Host, role string, opts executePlanOpts, primary Host, token string) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	parallelism := opts.parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var g errgroup.Group
+	sem := make(chan struct{}, parallelism)
+
+	for _, host := range hosts {
+		host := host
+		sem <- struct{}{}
+
+		g.Go(func() error {
+			defer func() { <-sem }()
+
+			user := hostUser(host, opts.user)
+
+			defaultExtra := opts.agentExtra
+			if role == roleServer {
+				defaultExtra = opts.serverExtra
+			}
+
+			dsCAFile, dsCertFile, dsKeyFile := "", "", ""
+			if role == roleServer && opts.haMode == haModeExternalDB {
+				dsCAFile, dsCertFile, dsKeyFile = opts.datastoreCAFile, opts.datastoreCertFile, opts.datastoreKeyFile
+			}
+			extraValue := k3sExtraArgsValue(k3sNativeArgs(host, dsCAFile, dsCertFile, dsKeyFile), hostExtraArgs(host, defaultExtra))
+			extra := extraArgsList(opts.k3sChannel, opts.k3sVersion, extraValue)
+
+			reportStatus(NodeStatus{Hostname: host.Hostname, Role: role, State: "joining"})
+
+			var args []string
+			if role == roleServer && opts.haMode == haModeExternalDB {
+				args = installCmdArgs(host, user, opts, extra)
+			} else if role == roleServer {
+				args = serverJoinCmdArgs(host, primary.IP, user, token, opts, extra)
+			} else {
+				args = agentJoinCmdArgs(host, primary.IP, user, token, opts, extra)
+			}
+
+			if _, err := runK3supCommand(args); err != nil {
+				reportStatus(NodeStatus{Hostname: host.Hostname, Role: role, State: "failed", Err: err})
+				return fmt.Errorf("joining %s %s: %w", role, host.Hostname, err)
+			}
+
+			reportStatus(NodeStatus{Hostname: host.Hostname, Role: role, State: "joined"})
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+// hostUser resolves the SSH user for a host, falling back to the
+// cluster-wide default when the host doesn't override it.
+func hostUser(host Host, defaultUser string) string {
+	if host.SSHUser != "" {
+		return host.SSHUser
+	}
+	return defaultUser
+}
+
+// hostExtraArgs returns the host's own --k3s-extra-args override, falling
+// back to the cluster-wide default when the host doesn't set one.
+func hostExtraArgs(host Host, defaultExtra string) string {
+	if host.K3sExtraArgs != "" {
+		return host.K3sExtraArgs
+	}
+	return defaultExtra
+}
+
+func hostnames(hosts []Host) []string {
+	names := make([]string, len(hosts))
+	for i, host := range hosts {
+		names[i] = host.Hostname
+	}
+	return names
+}
+
+// installCmdArgs builds the argv for a direct "k3sup install" exec.Command.
+// Unlike installCmdString (used for script output), every value is passed
+// as its own argument so the shell never re-parses plan-file content.
+func installCmdArgs(host Host, hostUser string, opts executePlanOpts, extra []string) []string {
+	args := []string{"install", "--host", host.IP, "--user", hostUser}
+	args = append(args, clusterOrDatastoreArgs(opts)...)
+	args = append(args, "--local-path", opts.kubeconfig, "--context", opts.contextName)
+	args = append(args, tlsSanArgsList(opts.tlsSans, host.TLSSan)...)
+	args = append(args, ipOverrideArgsList(host)...)
+	args = append(args, sshOverrideArgsList(host, opts.sshKey, opts.sshPort)...)
+	args = append(args, extra...)
+	return args
+}
+
+// serverJoinCmdArgs builds the argv for a direct "k3sup join --server".
+func serverJoinCmdArgs(host Host, primaryIP, hostUser, token string, opts executePlanOpts, extra []string) []string {
+	args := []string{"join", "--host", host.IP, "--server-host", primaryIP, "--server", "--node-token", token, "--user", hostUser}
+	args = append(args, tlsSanArgsList(opts.tlsSans, host.TLSSan)...)
+	args = append(args, ipOverrideArgsList(host)...)
+	args = append(args, sshOverrideArgsList(host, opts.sshKey, opts.sshPort)...)
+	args = append(args, extra...)
+	return args
+}
+
+// agentJoinCmdArgs builds the argv for a direct "k3sup join".
+func agentJoinCmdArgs(host Host, primaryIP, hostUser, token string, opts executePlanOpts, extra []string) []string {
+	args := []string{"join", "--host", host.IP, "--server-host", primaryIP, "--node-token", token, "--user", hostUser}
+	args = append(args, ipOverrideArgsList(host)...)
+	args = append(args, sshOverrideArgsList(host, opts.sshKey, opts.sshPort)...)
+	args = append(args, extra...)
+	return args
+}
+
+// nodeTokenFetchCmdArgs builds the argv for a direct "k3sup node-token".
+func nodeTokenFetchCmdArgs(host Host, hostUser string, sshKey string, sshPort int) []string {
+	args := []string{"node-token", "--host", host.IP, "--user", hostUser}
+	args = append(args, sshOverrideArgsList(host, sshKey, sshPort)...)
+	return args
+}
+
+// clusterOrDatastoreArgs returns the --cluster or --datastore/--token flags
+// for opts.haMode, mirroring plan.go's clusterOrDatastoreSt as an argv
+// slice. The datastore's own TLS files are native k3s flags, not k3sup
+// ones, so they travel inside --k3s-extra-args instead; see k3sNativeArgs.
+func clusterOrDatastoreArgs(opts executePlanOpts) []string {
+	switch opts.haMode {
+	case haModeExternalDB:
+		return []string{"--datastore", opts.datastoreEndpoint, "--token", opts.datastoreToken}
+	case haModeSingle:
+		return nil
+	default:
+		return []string{"--cluster"}
+	}
+}
+
+// tlsSanArgsList is the argv counterpart to tlsSanArgs.
+func tlsSanArgsList(globalSans, hostSans []string) []string {
+	sans := dedupeStrings(append(append([]string{}, globalSans...), hostSans...))
+	if len(sans) == 0 {
+		return nil
+	}
+	return []string{"--tls-san", strings.Join(sans, ",")}
+}
+
+// ipOverrideArgsList is the argv counterpart to ipOverrideArgs. It only
+// covers --ip: --node-external-ip is a k3s-native flag, not a k3sup one, so
+// it travels inside --k3s-extra-args instead; see k3sNativeArgs.
+func ipOverrideArgsList(host Host) []string {
+	if host.InternalIP == "" {
+		return nil
+	}
+	return []string{"--ip", host.InternalIP}
+}
+
+// sshOverrideArgsList is the argv counterpart to sshOverrideArgs.
+func sshOverrideArgsList(host Host, defaultSSHKey string, defaultSSHPort int) []string {
+	var args []string
+
+	sshKey := defaultSSHKey
+	if host.SSHKey != "" {
+		sshKey = host.SSHKey
+	}
+	if sshKey != "" {
+		args = append(args, "--ssh-key", sshKey)
+	}
+
+	sshPort := defaultSSHPort
+	if host.SSHPort != 0 {
+		sshPort = host.SSHPort
+	}
+	if sshPort != 0 && sshPort != 22 {
+		args = append(args, "--ssh-port", strconv.Itoa(sshPort))
+	}
+
+	return args
+}
+
+// extraArgsList builds the --k3s-channel/--k3s-version/--k3s-extra-args
+// argv for a single node, mirroring plan_spec.go's k3sChannelVersionArgs plus
+// the caller's chosen --k3s-extra-args value.
+func extraArgsList(channel, version, extra string) []string {
+	var args []string
+	if channel != "" {
+		args = append(args, "--k3s-channel", channel)
+	}
+	if version != "" {
+		args = append(args, "--k3s-version", version)
+	}
+	if extra != "" {
+		args = append(args, "--k3s-extra-args", extra)
+	}
+	return args
+}
+
+// runK3supCommand runs "k3sup" with the given argv directly, with no shell
+// involved, so plan-file content (labels, taints, extra-args, hostnames)
+// can never be reinterpreted as shell syntax.
+func runK3supCommand(args []string) (string, error) {
+	out, err := exec.Command("k3sup", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// waitForNodesReady polls "kubectl get nodes" until every name in hostnames
+// reports status Ready, or returns an error once timeout elapses.
+func waitForNodesReady(kubeconfig string, hostnames []string, timeout time.Duration) error {
+	if len(hostnames) == 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		out, err := exec.Command("kubectl", "--kubeconfig", kubeconfig, "get", "nodes", "--no-headers").CombinedOutput()
+		if err == nil && allNodesReady(string(out), hostnames) {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for nodes %v to report Ready", timeout, hostnames)
+		}
+
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func allNodesReady(kubectlOutput string, hostnames []string) bool {
+	ready := make(map[string]bool)
+	for _, line := range strings.Split(kubectlOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[1] == "Ready" {
+			ready[fields[0]] = true
+		}
+	}
+
+	for _, name := range hostnames {
+		if !ready[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// reportStatus logs --execute progress to stdout. It's deliberately a thin
+// seam: a future TUI/log writer can swap this out without touching the
+// execution logic above.
+func reportStatus(status NodeStatus) {
+	fmt.Println(status.String())
+}
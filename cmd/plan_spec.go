@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// ClusterSpec is the declarative, YAML-friendly counterpart to the flat
+// []Host array: it wraps the host list together with cluster-wide defaults
+// that fill in any field a host leaves empty. The flat array remains a
+// valid input in both JSON and YAML - it just carries no defaults.
+type ClusterSpec struct {
+	Hosts []Host `json:"hosts" yaml:"hosts"`
+
+	User    string   `json:"user,omitempty" yaml:"user,omitempty"`
+	SSHKey  string   `json:"ssh-key,omitempty" yaml:"ssh-key,omitempty"`
+	SSHPort int      `json:"ssh-port,omitempty" yaml:"ssh-port,omitempty"`
+	TLSSan  []string `json:"tls-san,omitempty" yaml:"tls-san,omitempty"`
+
+	K3sChannel string `json:"k3s-channel,omitempty" yaml:"k3s-channel,omitempty"`
+	K3sVersion string `json:"k3s-version,omitempty" yaml:"k3s-version,omitempty"`
+
+	ServerExtraArgs string `json:"server-extra-args,omitempty" yaml:"server-extra-args,omitempty"`
+	AgentExtraArgs  string `json:"agent-extra-args,omitempty" yaml:"agent-extra-args,omitempty"`
+
+	Servers int    `json:"servers,omitempty" yaml:"servers,omitempty"`
+	HAMode  string `json:"ha-mode,omitempty" yaml:"ha-mode,omitempty"`
+
+	DatastoreEndpoint string `json:"datastore-endpoint,omitempty" yaml:"datastore-endpoint,omitempty"`
+	DatastoreToken    string `json:"datastore-token,omitempty" yaml:"datastore-token,omitempty"`
+	DatastoreCAFile   string `json:"datastore-cafile,omitempty" yaml:"datastore-cafile,omitempty"`
+	DatastoreCertFile string `json:"datastore-certfile,omitempty" yaml:"datastore-certfile,omitempty"`
+	DatastoreKeyFile  string `json:"datastore-keyfile,omitempty" yaml:"datastore-keyfile,omitempty"`
+
+	KubeconfigPath string `json:"kubeconfig,omitempty" yaml:"kubeconfig,omitempty"`
+	Context        string `json:"context,omitempty" yaml:"context,omitempty"`
+}
+
+// detectFormat returns "yaml" or "json" for the given file, honoring an
+// explicit --format override ahead of the file extension.
+func detectFormat(path, formatFlag string) string {
+	if formatFlag != "" {
+		return formatFlag
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "json"
+	}
+}
+
+// parseClusterSpec accepts either the flat []Host array (JSON or YAML) or a
+// full ClusterSpec object, and always returns the latter.
+func parseClusterSpec(data []byte, format string) (*ClusterSpec, error) {
+	var hosts []Host
+	var hostsErr error
+	if format == "yaml" {
+		hostsErr = yaml.Unmarshal(data, &hosts)
+	} else {
+		hostsErr = json.Unmarshal(data, &hosts)
+	}
+	if hostsErr == nil && len(hosts) > 0 {
+		return &ClusterSpec{Hosts: hosts}, nil
+	}
+
+	var spec ClusterSpec
+	var specErr error
+	if format == "yaml" {
+		specErr = yaml.Unmarshal(data, &spec)
+	} else {
+		specErr = json.Unmarshal(data, &spec)
+	}
+	if specErr != nil {
+		return nil, specErr
+	}
+
+	return &spec, nil
+}
+
+// resolvedConfig is the cluster-wide configuration after folding together
+// (highest precedence first) an explicitly-given CLI flag, the ClusterSpec
+// default, then the flag's own built-in default.
+type resolvedConfig struct {
+	user        string
+	sshKey      string
+	sshPort     int
+	tlsSans     []string
+	k3sChannel  string
+	k3sVersion  string
+	serverExtra string
+	agentExtra  string
+	servers     int
+	haMode      string
+
+	datastoreEndpoint string
+	datastoreToken    string
+	datastoreCAFile   string
+	datastoreCertFile string
+	datastoreKeyFile  string
+
+	kubeconfig  string
+	contextName string
+}
+
+// resolveConfig merges cmd's flags with spec's defaults: a flag the user
+// actually passed always wins, otherwise a non-empty spec default is used,
+// otherwise the flag keeps its built-in default.
+func resolveConfig(cmd *cobra.Command, spec *ClusterSpec) resolvedConfig {
+	str := func(name, specVal string) string {
+		val, _ := cmd.Flags().GetString(name)
+		if cmd.Flags().Changed(name) || specVal == "" {
+			return val
+		}
+		return specVal
+	}
+	intVal := func(name string, specVal int) int {
+		val, _ := cmd.Flags().GetInt(name)
+		if cmd.Flags().Changed(name) || specVal == 0 {
+			return val
+		}
+		return specVal
+	}
+
+	tlsSans := spec.TLSSan
+	if cmd.Flags().Changed("tls-san") {
+		tlsSans, _ = cmd.Flags().GetStringSlice("tls-san")
+	}
+
+	return resolvedConfig{
+		user:        str("user", spec.User),
+		sshKey:      str("ssh-key", spec.SSHKey),
+		sshPort:     intVal("ssh-port", spec.SSHPort),
+		tlsSans:     dedupeStrings(tlsSans),
+		k3sChannel:  str("k3s-channel", spec.K3sChannel),
+		k3sVersion:  str("k3s-version", spec.K3sVersion),
+		serverExtra: str("server-k3s-extra-args", spec.ServerExtraArgs),
+		agentExtra:  str("agent-k3s-extra-args", spec.AgentExtraArgs),
+		servers:     intVal("servers", spec.Servers),
+		haMode:      str("ha-mode", spec.HAMode),
+
+		datastoreEndpoint: str("datastore-endpoint", spec.DatastoreEndpoint),
+		datastoreToken:    str("datastore-token", spec.DatastoreToken),
+		datastoreCAFile:   str("datastore-cafile", spec.DatastoreCAFile),
+		datastoreCertFile: str("datastore-certfile", spec.DatastoreCertFile),
+		datastoreKeyFile:  str("datastore-keyfile", spec.DatastoreKeyFile),
+
+		kubeconfig:  str("local-path", spec.KubeconfigPath),
+		contextName: str("context", spec.Context),
+	}
+}
+
+func dedupeStrings(in []string) []string {
+	out := make([]string, 0, len(in))
+	seen := make(map[string]bool)
+	for _, v := range in {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+// k3sChannelVersionArgs emits --k3s-channel/--k3s-version when set, to be
+// appended alongside (not instead of) any --k3s-extra-args override.
+func k3sChannelVersionArgs(channel, version string) string {
+	args := ""
+	if channel != "" {
+		args += fmt.Sprintf(` \
+--k3s-channel %s`, channel)
+	}
+	if version != "" {
+		args += fmt.Sprintf(` \
+--k3s-version %s`, version)
+	}
+	return args
+}
+
+func exampleClusterSpecYAML() string {
+	return `# Example k3sup cluster spec.
+# Cluster-wide defaults below are used whenever a host doesn't set its own
+# value; CLI flags such as --user or --servers override these defaults.
+user: root
+ssh-key: ~/.ssh/id_rsa
+tls-san:
+  - k3s.example.com
+server-extra-args: ""
+agent-extra-args: ""
+servers: 3
+ha-mode: embedded-etcd
+kubeconfig: kubeconfig
+context: default
+
+hosts:
+  - hostname: node-1
+    ip: 192.168.128.102
+    role: server
+  - hostname: node-2
+    ip: 192.168.128.103
+    role: agent
+    external-ip: 203.0.113.10
+    labels:
+      disktype: ssd
+    taints:
+      - "dedicated=gpu:NoSchedule"
+  - hostname: node-3
+    ip: 192.168.128.104
+    role: auto
+`
+}
+
+// MakePlanValidate parses a plan file and runs semantic checks without
+// emitting a script, for operators who maintain a cluster spec as part of
+// their inventory and want to lint it in CI.
+func MakePlanValidate() *cobra.Command {
+	var command = &cobra.Command{
+		Use:   "validate <file>",
+		Short: "Validate a plan file without generating a script",
+		Long: `Parse a JSON or YAML plan file (flat host array or a full ClusterSpec)
+and check it for common mistakes: duplicate IPs, invalid roles and a server
+count that can't form an HA quorum. Pass --probe to also check that each
+host's SSH port is reachable.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			formatFlag, _ := cmd.Flags().GetString("format")
+			probe, _ := cmd.Flags().GetBool("probe")
+
+			name := args[0]
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+
+			spec, err := parseClusterSpec(data, detectFormat(name, formatFlag))
+			if err != nil {
+				return err
+			}
+
+			cfg := resolveConfig(cmd, spec)
+
+			if err := validateHAModeFlag(cfg.haMode, cfg.datastoreEndpoint, cfg.datastoreToken); err != nil {
+				return err
+			}
+
+			if err := validateUniqueIPs(spec.Hosts); err != nil {
+				return err
+			}
+
+			serverHosts, _, err := assignRoles(spec.Hosts, cfg.servers, cfg.haMode)
+			if err != nil {
+				return err
+			}
+
+			if cfg.haMode == haModeSingle && len(serverHosts) != 1 {
+				return fmt.Errorf("--ha-mode=%s requires exactly 1 server, got %d", haModeSingle, len(serverHosts))
+			}
+
+			if probe {
+				if err := probeSSHPorts(spec.Hosts, cfg.sshPort, 3*time.Second); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("OK: %d host(s), %d server(s), ha-mode=%s\n", len(spec.Hosts), len(serverHosts), cfg.haMode)
+			return nil
+		},
+	}
+
+	command.Flags().Int("servers", 3, "Number of servers to use from the devices file")
+	command.Flags().String("ha-mode", haModeEmbeddedEtcd, "HA topology to validate: embedded-etcd, external-db or single")
+	command.Flags().String("datastore-endpoint", "", "Endpoint for an external datastore, used when --ha-mode=external-db")
+	command.Flags().String("datastore-token", "", "Shared secret token for an external datastore, required when --ha-mode=external-db")
+	command.Flags().String("format", "", "Force the input format to json or yaml instead of detecting it from the file extension")
+	command.Flags().Int("ssh-port", 22, "Default SSH port to probe when --probe is set")
+	command.Flags().Bool("probe", false, "Check that each host's SSH port is reachable")
+
+	return command
+}
+
+func validateUniqueIPs(hosts []Host) error {
+	seen := make(map[string]string)
+	for _, host := range hosts {
+		if existing, ok := seen[host.IP]; ok {
+			return fmt.Errorf("duplicate IP %s used by both %q and %q", host.IP, existing, host.Hostname)
+		}
+		seen[host.IP] = host.Hostname
+	}
+	return nil
+}
+
+func probeSSHPorts(hosts []Host, defaultSSHPort int, timeout time.Duration) error {
+	for _, host := range hosts {
+		port := defaultSSHPort
+		if host.SSHPort != 0 {
+			port = host.SSHPort
+		}
+
+		addr := net.JoinHostPort(host.IP, fmt.Sprintf("%d", port))
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return fmt.Errorf("host %q (%s) is not reachable on port %d: %w", host.Hostname, host.IP, port, err)
+		}
+		conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestAssignRoles(t *testing.T) {
+	t.Run("promotes auto hosts up to servers count", func(t *testing.T) {
+		hosts := []Host{
+			{Hostname: "node-1", IP: "10.0.0.1"},
+			{Hostname: "node-2", IP: "10.0.0.2"},
+			{Hostname: "node-3", IP: "10.0.0.3"},
+		}
+
+		servers, agents, err := assignRoles(hosts, 1, haModeEmbeddedEtcd)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(servers) != 1 || servers[0].Hostname != "node-1" {
+			t.Fatalf("expected node-1 promoted to server, got %+v", servers)
+		}
+		if len(agents) != 2 {
+			t.Fatalf("expected 2 agents, got %d", len(agents))
+		}
+	})
+
+	t.Run("honors explicit roles ahead of the servers count", func(t *testing.T) {
+		hosts := []Host{
+			{Hostname: "node-1", IP: "10.0.0.1", Role: roleAgent},
+			{Hostname: "node-2", IP: "10.0.0.2", Role: roleServer},
+			{Hostname: "node-3", IP: "10.0.0.3", Role: roleServer},
+			{Hostname: "node-4", IP: "10.0.0.4", Role: roleServer},
+		}
+
+		servers, agents, err := assignRoles(hosts, 0, haModeEmbeddedEtcd)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(servers) != 3 {
+			t.Fatalf("expected 3 explicit servers, got %d", len(servers))
+		}
+		if len(agents) != 1 || agents[0].Hostname != "node-1" {
+			t.Fatalf("expected node-1 as the only agent, got %+v", agents)
+		}
+	})
+
+	t.Run("mixed explicit and auto roles", func(t *testing.T) {
+		hosts := []Host{
+			{Hostname: "node-1", IP: "10.0.0.1", Role: roleServer},
+			{Hostname: "node-2", IP: "10.0.0.2", Role: roleAuto},
+			{Hostname: "node-3", IP: "10.0.0.3", Role: roleAuto},
+			{Hostname: "node-4", IP: "10.0.0.4", Role: roleAgent},
+		}
+
+		servers, agents, err := assignRoles(hosts, 2, haModeEmbeddedEtcd)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(servers) != 3 {
+			t.Fatalf("expected node-1 plus 2 auto-promoted servers, got %d", len(servers))
+		}
+		if len(agents) != 1 || agents[0].Hostname != "node-4" {
+			t.Fatalf("expected node-4 as the only agent, got %+v", agents)
+		}
+	})
+
+	t.Run("rejects servers count exceeding auto hosts", func(t *testing.T) {
+		hosts := []Host{
+			{Hostname: "node-1", IP: "10.0.0.1", Role: roleServer},
+			{Hostname: "node-2", IP: "10.0.0.2", Role: roleAgent},
+		}
+
+		if _, _, err := assignRoles(hosts, 1, haModeEmbeddedEtcd); err == nil {
+			t.Fatal("expected an error when --servers exceeds available auto hosts")
+		}
+	})
+
+	t.Run("rejects zero servers", func(t *testing.T) {
+		hosts := []Host{
+			{Hostname: "node-1", IP: "10.0.0.1", Role: roleAgent},
+		}
+
+		if _, _, err := assignRoles(hosts, 0, haModeEmbeddedEtcd); err == nil {
+			t.Fatal("expected an error when no server is planned")
+		}
+	})
+
+	t.Run("rejects an even server count for embedded etcd", func(t *testing.T) {
+		hosts := []Host{
+			{Hostname: "node-1", IP: "10.0.0.1"},
+			{Hostname: "node-2", IP: "10.0.0.2"},
+		}
+
+		if _, _, err := assignRoles(hosts, 2, haModeEmbeddedEtcd); err == nil {
+			t.Fatal("expected an error for an even embedded-etcd server count")
+		}
+	})
+
+	t.Run("allows an even server count for an external datastore", func(t *testing.T) {
+		hosts := []Host{
+			{Hostname: "node-1", IP: "10.0.0.1"},
+			{Hostname: "node-2", IP: "10.0.0.2"},
+		}
+
+		servers, _, err := assignRoles(hosts, 2, haModeExternalDB)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(servers) != 2 {
+			t.Fatalf("expected 2 servers, got %d", len(servers))
+		}
+	})
+
+	t.Run("rejects an invalid role", func(t *testing.T) {
+		hosts := []Host{
+			{Hostname: "node-1", IP: "10.0.0.1", Role: "controller"},
+		}
+
+		if _, _, err := assignRoles(hosts, 0, haModeEmbeddedEtcd); err == nil {
+			t.Fatal("expected an error for an invalid role")
+		}
+	})
+}
+
+func TestTlsSanArgs(t *testing.T) {
+	t.Run("no SANs", func(t *testing.T) {
+		if got := tlsSanArgs(nil, nil); got != "" {
+			t.Fatalf("expected empty string, got %q", got)
+		}
+	})
+
+	t.Run("a single global SAN", func(t *testing.T) {
+		want := ` \
+--tls-san k3s.example.com`
+		if got := tlsSanArgs([]string{"k3s.example.com"}, nil); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("many global SANs are comma-joined into a single flag, in order", func(t *testing.T) {
+		want := ` \
+--tls-san k3s.example.com,203.0.113.10,10.0.0.1`
+		got := tlsSanArgs([]string{"k3s.example.com", "203.0.113.10", "10.0.0.1"}, nil)
+		if got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("global and per-host SANs are merged, host SANs last", func(t *testing.T) {
+		want := ` \
+--tls-san k3s.example.com,203.0.113.10`
+		got := tlsSanArgs([]string{"k3s.example.com"}, []string{"203.0.113.10"})
+		if got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("duplicates across global and per-host are removed", func(t *testing.T) {
+		want := ` \
+--tls-san k3s.example.com,203.0.113.10`
+		got := tlsSanArgs([]string{"k3s.example.com", "203.0.113.10"}, []string{"203.0.113.10"})
+		if got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("empty entries are skipped", func(t *testing.T) {
+		want := ` \
+--tls-san k3s.example.com`
+		got := tlsSanArgs([]string{"", "k3s.example.com"}, []string{""})
+		if got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestK3sNativeArgs(t *testing.T) {
+	t.Run("node-name only", func(t *testing.T) {
+		host := Host{Hostname: "node-1"}
+
+		want := `--node-name node-1`
+		if got := k3sNativeArgs(host, "", "", ""); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("node-external-ip is included when set", func(t *testing.T) {
+		host := Host{Hostname: "node-1", ExternalIP: "203.0.113.10"}
+
+		want := `--node-name node-1 --node-external-ip 203.0.113.10`
+		if got := k3sNativeArgs(host, "", "", ""); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("labels are sorted by key", func(t *testing.T) {
+		host := Host{
+			Hostname: "node-1",
+			Labels: map[string]string{
+				"zone":     "us-east-1",
+				"disktype": "ssd",
+			},
+		}
+
+		want := `--node-name node-1 --node-label "disktype=ssd" --node-label "zone=us-east-1"`
+		if got := k3sNativeArgs(host, "", "", ""); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("taints are emitted in order after labels", func(t *testing.T) {
+		host := Host{
+			Hostname: "node-1",
+			Labels:   map[string]string{"disktype": "ssd"},
+			Taints:   []string{"dedicated=gpu:NoSchedule", "spot=true:PreferNoSchedule"},
+		}
+
+		want := `--node-name node-1 --node-label "disktype=ssd" --node-taint "dedicated=gpu:NoSchedule" --node-taint "spot=true:PreferNoSchedule"`
+		if got := k3sNativeArgs(host, "", "", ""); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("datastore TLS files are appended after labels and taints", func(t *testing.T) {
+		host := Host{
+			Hostname: "node-1",
+			Labels:   map[string]string{"disktype": "ssd"},
+			Taints:   []string{"dedicated=gpu:NoSchedule"},
+		}
+
+		want := `--node-name node-1 --node-label "disktype=ssd" --node-taint "dedicated=gpu:NoSchedule" --datastore-cafile /etc/ca.pem --datastore-certfile /etc/cert.pem --datastore-keyfile /etc/key.pem`
+		got := k3sNativeArgs(host, "/etc/ca.pem", "/etc/cert.pem", "/etc/key.pem")
+		if got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestK3sExtraArgsValue(t *testing.T) {
+	t.Run("no user extra args", func(t *testing.T) {
+		if got := k3sExtraArgsValue("--node-name node-1", ""); got != "--node-name node-1" {
+			t.Fatalf("got %q", got)
+		}
+	})
+
+	t.Run("native args and user extra args are space-joined", func(t *testing.T) {
+		want := `--node-name node-1 --protect-kernel-defaults`
+		if got := k3sExtraArgsValue("--node-name node-1", "--protect-kernel-defaults"); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	})
+}